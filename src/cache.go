@@ -0,0 +1,145 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// CacheStats reports how much work Resizer's on-disk resize cache has
+// saved over a run.
+type CacheStats struct {
+	Hits       int
+	Misses     int
+	BytesSaved int64
+}
+
+type resizeCache struct {
+	dir string
+
+	mu    sync.Mutex
+	stats CacheStats
+}
+
+func newResizeCache(dir string) *resizeCache {
+	if dir == "" {
+		return nil
+	}
+	return &resizeCache{dir: dir}
+}
+
+// maskIdentity returns the cache-key component for the configured
+// importance mask. Go func values have no usable per-closure identity —
+// reflect.ValueOf(fn).Pointer() returns the closure's *code* entry point,
+// which is identical across closures produced by the same factory with
+// different captured state (e.g. two face masks built by the same function
+// with different face boxes), so it can't tell those masks apart. Instead this
+// takes the caller-supplied ImportanceMaskKey verbatim: callers whose mask
+// closes over per-run state are responsible for setting it to something
+// that changes whenever the mask's behavior does.
+func maskIdentity(maskSet bool, maskKey string) string {
+	if !maskSet {
+		return "nil"
+	}
+	return "mask:" + maskKey
+}
+
+// key derives a cache key from the source file's identity and the params
+// that affect its resized output: absolute path, size, mtime, the
+// resize variant (scale or target spec), algorithm and importance mask.
+func (c *resizeCache) key(filename, variant string, algorithm Algorithm, maskSet bool, maskKey string) (string, error) {
+	abs, err := filepath.Abs(filename)
+	if err != nil {
+		return "", err
+	}
+	info, err := os.Stat(filename)
+	if err != nil {
+		return "", err
+	}
+
+	raw := fmt.Sprintf("%s|%d|%d|%s|%s|%s",
+		abs, info.Size(), info.ModTime().UnixNano(), variant, algorithm, maskIdentity(maskSet, maskKey))
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:]), nil
+}
+
+func (c *resizeCache) path(key string) string {
+	return filepath.Join(c.dir, key[0:2], key)
+}
+
+// load returns the cached resize of filename, if present, already decoded
+// back into an Image via loader. The Image's Encoded field carries the
+// exact bytes read from disk, so Save can write them back out verbatim
+// instead of re-encoding a decode of them (which, for lossy formats, can
+// produce different bytes and a different content-addressed hash).
+func (c *resizeCache) load(filename, ext, variant string, loader Loader, algorithm Algorithm, maskSet bool, maskKey string) (Image, bool) {
+	key, err := c.key(filename, variant, algorithm, maskSet, maskKey)
+	if err != nil {
+		return Image{}, false
+	}
+
+	data, err := ioutil.ReadFile(c.path(key))
+	if err != nil {
+		c.mu.Lock()
+		c.stats.Misses++
+		c.mu.Unlock()
+		return Image{}, false
+	}
+
+	cached, err := loader.Decode(bytes.NewReader(data))
+	if err != nil {
+		c.mu.Lock()
+		c.stats.Misses++
+		c.mu.Unlock()
+		return Image{}, false
+	}
+
+	c.mu.Lock()
+	c.stats.Hits++
+	c.stats.BytesSaved += int64(len(data))
+	c.mu.Unlock()
+
+	b := cached.Bounds()
+	return Image{
+		Filename: filename,
+		Ext:      ext,
+		Width:    uint(b.Dx()),
+		Height:   uint(b.Dy()),
+		Data:     cached,
+		Encoded:  data,
+	}, true
+}
+
+// store saves encoded under filename's cache key for future runs.
+func (c *resizeCache) store(filename, variant string, algorithm Algorithm, maskSet bool, maskKey string, encoded []byte) error {
+	key, err := c.key(filename, variant, algorithm, maskSet, maskKey)
+	if err != nil {
+		return err
+	}
+
+	path := c.path(key)
+	if err := ensureDir(filepath.Dir(path)); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(path, encoded, 0644)
+}
+
+func (c *resizeCache) snapshot() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats
+}
+
+// CacheStats reports resize cache hits, misses and bytes saved so far.
+// It returns a zero value if no CacheDir was configured.
+func (r *Resizer) CacheStats() CacheStats {
+	if r.cache == nil {
+		return CacheStats{}
+	}
+	return r.cache.snapshot()
+}
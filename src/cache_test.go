@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+)
+
+func TestCacheKeyVariesByMaskKey(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a.png")
+	if err := ioutil.WriteFile(src, []byte("fake"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := &resizeCache{dir: dir}
+
+	keyNil, err := c.key(src, "scale:1", AlgorithmSeam, false, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyA, err := c.key(src, "scale:1", AlgorithmSeam, true, "boxes-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyB, err := c.key(src, "scale:1", AlgorithmSeam, true, "boxes-b")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyARepeat, err := c.key(src, "scale:1", AlgorithmSeam, true, "boxes-a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if keyNil == keyA || keyA == keyB {
+		t.Fatalf("expected distinct keys per mask key, got nil=%s a=%s b=%s", keyNil, keyA, keyB)
+	}
+	if keyA != keyARepeat {
+		t.Fatalf("expected the same mask key to produce a stable cache key, got %s != %s", keyA, keyARepeat)
+	}
+}
+
+func TestCacheLoadReturnsExactStoredBytes(t *testing.T) {
+	srcDir := t.TempDir()
+	cacheDir := t.TempDir()
+	src := filepath.Join(srcDir, "a.png")
+	if err := ioutil.WriteFile(src, []byte("fake-source"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	img := image.NewNRGBA(image.Rect(0, 0, 4, 4))
+	for y := 0; y < 4; y++ {
+		for x := 0; x < 4; x++ {
+			img.Set(x, y, color.White)
+		}
+	}
+	loader := NewPNGLoader()
+	var buf bytes.Buffer
+	if err := loader.Encode(&buf, img); err != nil {
+		t.Fatal(err)
+	}
+	encoded := buf.Bytes()
+
+	c := &resizeCache{dir: cacheDir}
+	if err := c.store(src, "scale:1", AlgorithmLanczos3, false, "", encoded); err != nil {
+		t.Fatal(err)
+	}
+
+	got, hit := c.load(src, ".png", "scale:1", loader, AlgorithmLanczos3, false, "")
+	if !hit {
+		t.Fatal("expected a cache hit")
+	}
+	if !bytes.Equal(got.Encoded, encoded) {
+		t.Fatalf("load returned different bytes than were stored: got %d bytes, want %d bytes", len(got.Encoded), len(encoded))
+	}
+
+	stats := c.snapshot()
+	if stats.Hits != 1 || stats.Misses != 0 {
+		t.Fatalf("got stats %+v, want 1 hit, 0 misses", stats)
+	}
+
+	// A different mask key must miss even though everything else matches.
+	if _, hit := c.load(src, ".png", "scale:1", loader, AlgorithmLanczos3, true, "boxes-a"); hit {
+		t.Fatal("expected a miss after changing the importance mask key")
+	}
+}
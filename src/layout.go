@@ -0,0 +1,71 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func ensureDir(dir string) error {
+	return os.MkdirAll(dir, 0755)
+}
+
+// Layout selects how Save names and lays out its output files.
+type Layout string
+
+const (
+	// LayoutFlat writes every output directly into saveDir, named from the
+	// source basename and output dimensions, or from the source basename
+	// and the Image's Suffix when one is set.
+	LayoutFlat Layout = "flat"
+	// LayoutMirrorSource recreates the source file's path relative to the
+	// resizer's source dir underneath saveDir.
+	LayoutMirrorSource Layout = "mirror-source"
+	// LayoutContentAddressed names each output after the SHA-256 of its
+	// encoded bytes, sharded into two levels of two-character directories,
+	// so identical resize outputs dedupe for free.
+	LayoutContentAddressed Layout = "content-addressed"
+)
+
+// outputPath returns the path Save should write encoded to, creating any
+// directories the layout needs along the way.
+func (r *Resizer) outputPath(img Image, encoded []byte) (string, error) {
+	switch r.layout {
+	case LayoutMirrorSource:
+		rel, err := filepath.Rel(r.dir, img.Filename)
+		if err != nil {
+			return "", err
+		}
+		dir, file := filepath.Split(rel)
+		file = strings.TrimSuffix(file, img.Ext) + img.Suffix + img.Ext
+		path := filepath.Join(r.saveDir, dir, file)
+		if err := ensureDir(filepath.Dir(path)); err != nil {
+			return "", err
+		}
+		return path, nil
+
+	case LayoutContentAddressed:
+		sum := sha256.Sum256(encoded)
+		hash := hex.EncodeToString(sum[:])
+		dir := filepath.Join(r.saveDir, hash[0:2], hash[2:4])
+		if err := ensureDir(dir); err != nil {
+			return "", err
+		}
+		return filepath.Join(dir, hash+img.Ext), nil
+
+	default: // LayoutFlat
+		base := filepath.Base(img.Filename)
+		base = strings.TrimSuffix(base, img.Ext)
+
+		var name string
+		if img.Suffix != "" {
+			name = fmt.Sprintf("%s%s%s", base, img.Suffix, img.Ext)
+		} else {
+			name = fmt.Sprintf("%s_%dx%d%s", base, img.Width, img.Height, img.Ext)
+		}
+		return filepath.Join(r.saveDir, name), nil
+	}
+}
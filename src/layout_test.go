@@ -0,0 +1,62 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestContentAddressedLayoutDedupesIdenticalOutput(t *testing.T) {
+	saveDir := t.TempDir()
+	r := &Resizer{saveDir: saveDir, layout: LayoutContentAddressed}
+
+	encoded := []byte("identical resize bytes")
+	imgA := Image{Filename: "a.png", Ext: ".png"}
+	imgB := Image{Filename: "b.png", Ext: ".png"}
+
+	pathA, err := r.outputPath(imgA, encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pathB, err := r.outputPath(imgB, encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if pathA != pathB {
+		t.Fatalf("expected two equal-content resizes to share a path, got %q and %q", pathA, pathB)
+	}
+
+	// A different output must land at a different path.
+	pathC, err := r.outputPath(Image{Filename: "c.png", Ext: ".png"}, []byte("different bytes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pathC == pathA {
+		t.Fatalf("expected different content to produce a different path, both got %q", pathA)
+	}
+}
+
+func TestMirrorSourceLayoutRecreatesSourceSubdirectories(t *testing.T) {
+	srcDir := t.TempDir()
+	saveDir := t.TempDir()
+	r := &Resizer{dir: srcDir, saveDir: saveDir, layout: LayoutMirrorSource}
+
+	img := Image{
+		Filename: filepath.Join(srcDir, "sub", "a.png"),
+		Ext:      ".png",
+	}
+
+	path, err := r.outputPath(img, []byte("bytes"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := filepath.Join(saveDir, "sub", "a.png")
+	if path != want {
+		t.Fatalf("got path %q, want %q", path, want)
+	}
+	if info, err := os.Stat(filepath.Dir(path)); err != nil || !info.IsDir() {
+		t.Fatalf("expected outputPath to create %q", filepath.Dir(path))
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+	"golang.org/x/image/webp"
+)
+
+// Loader decodes and encodes one image format. Composing Loaders lets
+// NewResizer support new formats (e.g. HEIC via cgo) without touching the
+// core Resize/Save pipeline.
+type Loader interface {
+	// Extensions returns the file extensions this Loader handles, lowercase
+	// and including the leading dot (e.g. ".jpg").
+	Extensions() []string
+	Decode(r io.Reader) (image.Image, error)
+	Encode(w io.Writer, img image.Image) error
+}
+
+type jpegLoader struct{}
+
+func (jpegLoader) Extensions() []string                { return []string{".jpg", ".jpeg"} }
+func (jpegLoader) Decode(r io.Reader) (image.Image, error) { return jpeg.Decode(r) }
+func (jpegLoader) Encode(w io.Writer, img image.Image) error {
+	return jpeg.Encode(w, img, nil)
+}
+
+type pngLoader struct{}
+
+func (pngLoader) Extensions() []string                     { return []string{".png"} }
+func (pngLoader) Decode(r io.Reader) (image.Image, error)  { return png.Decode(r) }
+func (pngLoader) Encode(w io.Writer, img image.Image) error { return png.Encode(w, img) }
+
+type gifLoader struct{}
+
+func (gifLoader) Extensions() []string                     { return []string{".gif"} }
+func (gifLoader) Decode(r io.Reader) (image.Image, error)  { return gif.Decode(r) }
+func (gifLoader) Encode(w io.Writer, img image.Image) error { return gif.Encode(w, img, nil) }
+
+type bmpLoader struct{}
+
+func (bmpLoader) Extensions() []string                     { return []string{".bmp"} }
+func (bmpLoader) Decode(r io.Reader) (image.Image, error)  { return bmp.Decode(r) }
+func (bmpLoader) Encode(w io.Writer, img image.Image) error { return bmp.Encode(w, img) }
+
+type tiffLoader struct{}
+
+func (tiffLoader) Extensions() []string                    { return []string{".tif", ".tiff"} }
+func (tiffLoader) Decode(r io.Reader) (image.Image, error) { return tiff.Decode(r) }
+func (tiffLoader) Encode(w io.Writer, img image.Image) error {
+	return tiff.Encode(w, img, nil)
+}
+
+// webpLoader only supports decoding: golang.org/x/image/webp has no encoder,
+// so Encode reports an explicit error instead of silently writing nothing.
+type webpLoader struct{}
+
+func (webpLoader) Extensions() []string                    { return []string{".webp"} }
+func (webpLoader) Decode(r io.Reader) (image.Image, error) { return webp.Decode(r) }
+func (webpLoader) Encode(w io.Writer, img image.Image) error {
+	return errUnsupportedEncode(".webp")
+}
+
+func errUnsupportedEncode(ext string) error {
+	return &unsupportedEncodeError{ext: ext}
+}
+
+type unsupportedEncodeError struct{ ext string }
+
+func (e *unsupportedEncodeError) Error() string {
+	return "resizer: encoding " + e.ext + " is not supported"
+}
+
+// NewJPEGLoader, NewPNGLoader, NewGIFLoader, NewWebPLoader, NewBMPLoader and
+// NewTIFFLoader construct the built-in Loaders. They're exported so callers
+// composing a custom chain can reuse them alongside their own Loaders.
+func NewJPEGLoader() Loader { return jpegLoader{} }
+func NewPNGLoader() Loader  { return pngLoader{} }
+func NewGIFLoader() Loader  { return gifLoader{} }
+func NewWebPLoader() Loader { return webpLoader{} }
+func NewBMPLoader() Loader  { return bmpLoader{} }
+func NewTIFFLoader() Loader { return tiffLoader{} }
+
+func defaultLoaders() []Loader {
+	return []Loader{
+		NewJPEGLoader(),
+		NewPNGLoader(),
+		NewGIFLoader(),
+		NewWebPLoader(),
+		NewBMPLoader(),
+		NewTIFFLoader(),
+	}
+}
+
+// newLoaderRegistry indexes loaders by extension. Loaders passed in extra
+// are registered after the defaults, so they can override a built-in
+// Loader's extension if needed.
+func newLoaderRegistry(extra []Loader) map[string]Loader {
+	registry := make(map[string]Loader)
+	for _, l := range defaultLoaders() {
+		for _, ext := range l.Extensions() {
+			registry[ext] = l
+		}
+	}
+	for _, l := range extra {
+		for _, ext := range l.Extensions() {
+			registry[ext] = l
+		}
+	}
+	return registry
+}
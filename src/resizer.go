@@ -1,27 +1,23 @@
 package main
 
 import (
+	"bytes"
 	"context"
 	"flag"
 	"fmt"
 	"golang.org/x/sync/errgroup"
 	"image"
-	"image/jpeg"
-	"image/png"
-	"io/ioutil"
+	"io/fs"
 	"log"
+	"math"
 	"os"
 	"path/filepath"
-	"time"
+	"sync"
 
 	"github.com/nfnt/resize"
 )
 
-const (
-	extJpg = ".jpg"
-	extJpeg = ".jpeg"
-	extPng = ".png"
-)
+const defaultWorkers = 4
 
 type Image struct {
 	Filename string
@@ -29,6 +25,13 @@ type Image struct {
 	Width    uint
 	Height   uint
 	Data     image.Image
+	// Suffix, set when the image came from a TargetSpec, is appended to the
+	// basename instead of the usual WxH filename component.
+	Suffix string
+	// Encoded, when set, is the already-encoded form of Data (e.g. read
+	// back from the resize cache). Save writes these bytes out verbatim
+	// instead of re-encoding, so cache hits reproduce byte-identical output.
+	Encoded []byte
 }
 
 func width(w int, sc int) uint {
@@ -51,12 +54,40 @@ func height(h int, sc int) uint {
 	return nh
 }
 
+// fitDimensions scales srcW x srcH down or up to fit within maxW x maxH
+// while preserving aspect ratio. A zero bound on one axis leaves that axis
+// unconstrained.
+func fitDimensions(srcW, srcH, maxW, maxH int) (uint, uint) {
+	sw, sh := float64(srcW), float64(srcH)
+
+	var scale float64
+	switch {
+	case maxW > 0 && maxH > 0:
+		scale = math.Min(float64(maxW)/sw, float64(maxH)/sh)
+	case maxW > 0:
+		scale = float64(maxW) / sw
+	case maxH > 0:
+		scale = float64(maxH) / sh
+	default:
+		scale = 1
+	}
+
+	return uint(math.Round(sw * scale)), uint(math.Round(sh * scale))
+}
+
 type Resizer struct {
-	files []string
 	dir string
 	saveDir string
 	sc int
 	recursive bool
+	algorithm Algorithm
+	mask ImportanceMask
+	maskKey string
+	loaders map[string]Loader
+	layout Layout
+	workers int
+	cache *resizeCache
+	targets []TargetSpec
 
 	tasks chan Image
 }
@@ -66,140 +97,297 @@ type ResizerOptions struct {
 	SaveDir string
 	Scale int
 	Recursive bool
+	// Algorithm selects the resize strategy. Defaults to AlgorithmLanczos3.
+	Algorithm Algorithm
+	// ImportanceMask, when set, is consulted by AlgorithmSeam to keep
+	// important regions (e.g. detected faces) out of the carved seams.
+	ImportanceMask ImportanceMask
+	// ImportanceMaskKey identifies ImportanceMask's current configuration
+	// for the resize cache. Go func values don't carry a usable
+	// per-closure identity, so if ImportanceMask closes over state that
+	// can vary between runs (e.g. per-image face boxes), set this to a
+	// string that changes whenever the mask's behavior does — otherwise
+	// the cache can't tell two differently-configured masks apart.
+	ImportanceMaskKey string
+	// Loaders registers extra format support (e.g. HEIC via cgo) on top of
+	// the built-in JPEG, PNG, GIF, WebP, BMP and TIFF loaders.
+	Loaders []Loader
+	// Layout selects the output directory layout. Defaults to LayoutFlat.
+	Layout Layout
+	// Workers bounds how many goroutines run concurrently in each pipeline
+	// stage (decode+resize, then encode+save). Defaults to 4.
+	Workers int
+	// CacheDir, when set, persists resized output keyed by source path,
+	// size, mtime, scale, algorithm and format, so unchanged sources skip
+	// decode+resize on later runs.
+	CacheDir string
+	// Targets, when set, produces every named variant from a single decode
+	// of each source instead of the single Scale-based output.
+	Targets []TargetSpec
 }
 
 func NewResizer(ctx context.Context, opt ResizerOptions) (*Resizer, error) {
+	algorithm := opt.Algorithm
+	if algorithm == "" {
+		algorithm = AlgorithmLanczos3
+	}
+	layout := opt.Layout
+	if layout == "" {
+		layout = LayoutFlat
+	}
+	workers := opt.Workers
+	if workers <= 0 {
+		workers = defaultWorkers
+	}
+	if len(opt.Targets) == 0 && opt.Scale == 0 {
+		return nil, fmt.Errorf("resizer: Scale must be non-zero when Targets is empty")
+	}
+	if err := validateTargets(opt.Targets); err != nil {
+		return nil, err
+	}
+
 	r := &Resizer{
 		dir: opt.Dir,
 		saveDir: opt.SaveDir,
 		sc: opt.Scale,
 		recursive: opt.Recursive,
-		files: make([]string, 0),
-		tasks: make(chan Image, 1),
-	}
-	if err := r.getImages(ctx); err != nil {
-		return nil, err
+		algorithm: algorithm,
+		mask: opt.ImportanceMask,
+		maskKey: opt.ImportanceMaskKey,
+		loaders: newLoaderRegistry(opt.Loaders),
+		layout: layout,
+		workers: workers,
+		cache: newResizeCache(opt.CacheDir),
+		targets: opt.Targets,
+		tasks: make(chan Image, workers),
 	}
 	return r, nil
 }
 
-func (r *Resizer) getImages(ctx context.Context) error {
-	fileInfo, err := ioutil.ReadDir(r.dir)
-	if err != nil {
-		return err
+// walk feeds the decode+resize workers with source filenames, descending
+// into subdirectories only when r.recursive is set. It replaces the old
+// getImages, which recursed by re-scanning r.dir itself instead of the
+// subdirectory being visited.
+func (r *Resizer) walk(ctx context.Context, out chan<- string) error {
+	return filepath.WalkDir(r.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if path != r.dir && !r.recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if _, ok := r.loaders[filepath.Ext(path)]; !ok {
+			return nil
+		}
+		select {
+		case out <- path:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	})
+}
+
+// decodeAndResize produces one Image per configured target (or one, in the
+// legacy single-Scale case) from a single decode of filename.
+func (r *Resizer) decodeAndResize(filename string) ([]Image, error) {
+	ext := filepath.Ext(filename)
+	loader, ok := r.loaders[ext]
+	if !ok {
+		return nil, fmt.Errorf("resizer: no loader registered for %q", ext)
 	}
 
-	for _, file := range fileInfo {
-		ext := filepath.Ext(file.Name())
+	specs := r.specs()
+	results := make([]Image, len(specs))
+	missing := make([]int, 0, len(specs))
 
-		if r.recursive {
-			if file.IsDir() {
-				if err := r.getImages(ctx); err != nil {
-					return err
-				}
-			} else {
-				if ext == extJpg || ext == extJpeg || ext == extPng {
-					r.files = append(r.files, filepath.Join(r.dir, file.Name()))
-				}
-			}
-		} else {
-			if ext == extJpg || ext == extJpeg || ext == extPng {
-				r.files = append(r.files, filepath.Join(r.dir, file.Name()))
+	if r.cache != nil {
+		for i, sp := range specs {
+			if img, hit := r.cache.load(filename, ext, sp.variant, loader, r.algorithm, r.mask != nil, r.maskKey); hit {
+				img.Suffix = sp.suffix
+				results[i] = img
+				continue
 			}
+			missing = append(missing, i)
+		}
+		if len(missing) == 0 {
+			return results, nil
+		}
+	} else {
+		for i := range specs {
+			missing = append(missing, i)
 		}
 	}
-	return nil
-}
 
-func (r *Resizer) Resize(ctx context.Context) error {
-	var (
-		img  Image
-		source image.Image
-		file *os.File
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = file.Close() }()
 
-		err error
-	)
+	source, err := loader.Decode(file)
+	if err != nil {
+		return nil, err
+	}
+	srcW, srcH := source.Bounds().Dx(), source.Bounds().Dy()
 
-	for _, filename := range r.files {
-		file, err = os.Open(filename)
-		if err != nil {
-			return err
-		}
+	for _, i := range missing {
+		sp := specs[i]
+		width, height := sp.dimensions(srcW, srcH)
 
-		ext := filepath.Ext(filename)
-		switch {
-		case ext == extJpeg || ext == extJpg:
-			source, err = jpeg.Decode(file)
-			if err != nil {
-				return err
-			}
-		case ext == extPng:
-			source, err = png.Decode(file)
+		var m image.Image
+		switch r.algorithm {
+		case AlgorithmSeam:
+			var err error
+			m, err = seamCarve(source, int(width), int(height), r.mask)
 			if err != nil {
-				return err
+				return nil, err
 			}
+		case AlgorithmNearest:
+			m = resize.Resize(width, height, source, resize.NearestNeighbor)
+		default:
+			m = resize.Resize(width, height, source, resize.Lanczos3)
 		}
 
-		width := width(source.Bounds().Max.X, r.sc)
-		height := height(source.Bounds().Max.Y, r.sc)
-
-		m := resize.Resize(width, height, source, resize.Lanczos3)
-		img = Image{
+		img := Image{
 			Filename: filename,
 			Ext: ext,
 			Width: width,
 			Height: height,
 			Data: m,
+			Suffix: sp.suffix,
+		}
+
+		if r.cache != nil {
+			var buf bytes.Buffer
+			if err := loader.Encode(&buf, img.Data); err != nil {
+				return nil, err
+			}
+			if err := r.cache.store(filename, sp.variant, r.algorithm, r.mask != nil, r.maskKey, buf.Bytes()); err != nil {
+				return nil, err
+			}
+			img.Encoded = buf.Bytes()
 		}
-		r.tasks <- img
+
+		results[i] = img
 	}
-	defer func() {
-		_ = file.Close()
-		close(r.tasks)
-	}()
 
-	return nil
+	return results, nil
 }
 
-func (r *Resizer) Save(ctx context.Context) error {
-	var (
-		err error
-		out *os.File
-	)
+// Resize runs the file walker and a bounded pool of decode+resize workers,
+// streaming each result onto r.tasks for Save to consume. It returns once
+// every source file has been walked and resized, closing r.tasks behind it.
+func (r *Resizer) Resize(ctx context.Context) error {
+	eg, ctx := errgroup.WithContext(ctx)
 
-	if _, err := os.Stat(r.saveDir); os.IsNotExist(err) {
-		if err := os.Mkdir(r.saveDir, 0644); err != nil {
-			return err
-		}
+	filesCh := make(chan string, r.workers)
+	eg.Go(func() error {
+		defer close(filesCh)
+		return r.walk(ctx, filesCh)
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < r.workers; i++ {
+		wg.Add(1)
+		eg.Go(func() error {
+			defer wg.Done()
+			for filename := range filesCh {
+				imgs, err := r.decodeAndResize(filename)
+				if err != nil {
+					return err
+				}
+				for _, img := range imgs {
+					select {
+					case r.tasks <- img:
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				}
+			}
+			return nil
+		})
 	}
 
-	for img := range r.tasks {
-		filename := fmt.Sprintf(
-			"%dx%d_%d.%s",
-			img.Width, img.Height, time.Since(time.Unix(0, time.Now().Unix())), img.Ext,
-		)
+	eg.Go(func() error {
+		wg.Wait()
+		close(r.tasks)
+		return nil
+	})
 
-		out, err = os.Create(filename)
-		if err != nil {
-			return err
+	return eg.Wait()
+}
+
+func (r *Resizer) saveOne(img Image) error {
+	encoded := img.Encoded
+	if encoded == nil {
+		loader, ok := r.loaders[img.Ext]
+		if !ok {
+			return fmt.Errorf("resizer: no loader registered for %q", img.Ext)
 		}
 
-		switch {
-		case img.Ext == extJpeg || img.Ext == extJpg:
-			if err := jpeg.Encode(out, img.Data, nil); err != nil {
-				return err
-			}
-		case img.Ext == extPng:
-			if err := png.Encode(out, img.Data); err != nil {
-				return err
-			}
+		var buf bytes.Buffer
+		if err := loader.Encode(&buf, img.Data); err != nil {
+			return err
 		}
+		encoded = buf.Bytes()
 	}
-	defer func() {
+
+	path, err := r.outputPath(img, encoded)
+	if err != nil {
+		return err
+	}
+
+	out, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := out.Write(encoded); err != nil {
 		_ = out.Close()
-	}()
+		return err
+	}
+	return out.Close()
+}
 
-	return nil
+// Save runs a bounded pool of encode+save workers that drain r.tasks until
+// Resize closes it. Buffered tasks are taken before cancellation is
+// checked; a worker returns ctx.Err() only when ctx is canceled while it
+// waits for a task.
+func (r *Resizer) Save(ctx context.Context) error {
+	if err := ensureDir(r.saveDir); err != nil {
+		return err
+	}
+
+	eg, ctx := errgroup.WithContext(ctx)
+	for i := 0; i < r.workers; i++ {
+		eg.Go(func() error {
+			for {
+				var (
+					img Image
+					ok  bool
+				)
+				select {
+				case img, ok = <-r.tasks:
+				default:
+					select {
+					case img, ok = <-r.tasks:
+					case <-ctx.Done():
+						return ctx.Err()
+					}
+				}
+				if !ok {
+					return nil
+				}
+				if err := r.saveOne(img); err != nil {
+					return err
+				}
+			}
+		})
+	}
+	return eg.Wait()
 }
 
 func main() {
@@ -208,6 +396,10 @@ func main() {
 		sc      = flag.Int("sc", 1, "Set x times scaling value. Negative means downscaling. Positive up scaling.")
 		dir     = flag.String("d", "", "Sets the path to dir containing images.")
 		saveDir = flag.String("p", *dir, "Sets the path to save resized images. Default value -d flag value.")
+		workers = flag.Int("w", defaultWorkers, "Sets the number of concurrent workers per pipeline stage.")
+		cacheDir = flag.String("cache", "", "Sets a directory to cache resized output in across runs. Disabled if empty.")
+		algorithm = flag.String("algorithm", string(AlgorithmLanczos3), "Sets the resize algorithm: lanczos3, nearest or seam.")
+		layout    = flag.String("layout", string(LayoutFlat), "Sets the output layout: flat, mirror-source or content-addressed.")
 	)
 	flag.Parse()
 
@@ -218,11 +410,18 @@ func main() {
 		log.Fatalf("Scale value should be different from zero")
 	}
 
+	// Targets and Loaders have no CLI equivalent: they're library-only
+	// extension points (custom output profiles, extra decode/encode
+	// formats) aimed at callers embedding Resizer, not the CLI user.
 	resizer, err := NewResizer(context.Background(), ResizerOptions{
 		Dir:       *dir,
 		SaveDir:   *saveDir,
 		Scale:     *sc,
 		Recursive: *r,
+		Workers:   *workers,
+		CacheDir:  *cacheDir,
+		Algorithm: Algorithm(*algorithm),
+		Layout:    Layout(*layout),
 	})
 	if err != nil {
 		log.Fatal(err)
@@ -239,4 +438,9 @@ func main() {
 	if err := resizer.Save(runCtx); err != nil {
 		log.Fatal(err)
 	}
+
+	if *cacheDir != "" {
+		stats := resizer.CacheStats()
+		log.Printf("cache: %d hits, %d misses, %d bytes saved", stats.Hits, stats.Misses, stats.BytesSaved)
+	}
 }
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"context"
+	"image"
+	"image/color"
+	"image/png"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+func writePNG(t *testing.T, path string, w, h int) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, color.White)
+		}
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestNewResizerRejectsZeroScaleWithoutTargets(t *testing.T) {
+	dir := t.TempDir()
+	_, err := NewResizer(context.Background(), ResizerOptions{Dir: dir, SaveDir: dir})
+	if err == nil {
+		t.Fatal("expected an error for Scale: 0 with no Targets configured")
+	}
+}
+
+func TestWalkRespectsRecursive(t *testing.T) {
+	dir := t.TempDir()
+	writePNG(t, filepath.Join(dir, "top.png"), 10, 10)
+	writePNG(t, filepath.Join(dir, "sub", "nested.png"), 10, 10)
+	if err := os.WriteFile(filepath.Join(dir, "ignore.txt"), []byte("not an image"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	collect := func(recursive bool) []string {
+		r := &Resizer{dir: dir, recursive: recursive, loaders: newLoaderRegistry(nil)}
+		out := make(chan string, 10)
+		if err := r.walk(context.Background(), out); err != nil {
+			t.Fatal(err)
+		}
+		close(out)
+		var got []string
+		for p := range out {
+			got = append(got, filepath.Base(p))
+		}
+		sort.Strings(got)
+		return got
+	}
+
+	if got := collect(false); len(got) != 1 || got[0] != "top.png" {
+		t.Fatalf("non-recursive walk: got %v, want [top.png]", got)
+	}
+	if got := collect(true); len(got) != 2 || got[0] != "nested.png" || got[1] != "top.png" {
+		t.Fatalf("recursive walk: got %v, want [nested.png top.png]", got)
+	}
+}
+
+func TestResizeAndSavePipeline(t *testing.T) {
+	srcDir := t.TempDir()
+	saveDir := t.TempDir()
+	writePNG(t, filepath.Join(srcDir, "a.png"), 20, 10)
+	writePNG(t, filepath.Join(srcDir, "b.png"), 20, 10)
+
+	r, err := NewResizer(context.Background(), ResizerOptions{
+		Dir:     srcDir,
+		SaveDir: saveDir,
+		Scale:   -2,
+		Workers: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := r.Resize(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Save(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(saveDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("got %d output files, want 2", len(entries))
+	}
+	for _, e := range entries {
+		if e.Name() != "a_10x5.png" && e.Name() != "b_10x5.png" {
+			t.Errorf("unexpected output file %q", e.Name())
+		}
+	}
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+)
+
+// Algorithm selects how Resize scales an image.
+type Algorithm string
+
+const (
+	AlgorithmLanczos3 Algorithm = "lanczos3"
+	AlgorithmNearest  Algorithm = "nearest"
+	AlgorithmSeam     Algorithm = "seam"
+)
+
+// ImportanceMask reports, for the image it is given, which pixels must be
+// kept. It returns a same-sized grid of extra energy to add on top of the
+// Sobel map; regions that should never be carved (e.g. detected faces)
+// should return math.Inf(1) so a seam can never pass through them.
+// seamCarve calls it before every seam removal with the image as carved so
+// far, not only with the source; while horizontal seams are removed that
+// image is transposed, so its x and y axes are swapped.
+type ImportanceMask func(img image.Image) [][]float64
+
+// toGray converts img to a plain grayscale pixel grid for energy analysis.
+func toGray(img image.Image) [][]float64 {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	gray := make([][]float64, h)
+	for y := 0; y < h; y++ {
+		gray[y] = make([]float64, w)
+		for x := 0; x < w; x++ {
+			r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+			gray[y][x] = 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(bl)
+		}
+	}
+	return gray
+}
+
+// sobelEnergy computes a per-pixel energy map via Gx/Gy convolution.
+func sobelEnergy(gray [][]float64) [][]float64 {
+	h := len(gray)
+	w := len(gray[0])
+	energy := make([][]float64, h)
+
+	at := func(y, x int) float64 {
+		if y < 0 {
+			y = 0
+		}
+		if y >= h {
+			y = h - 1
+		}
+		if x < 0 {
+			x = 0
+		}
+		if x >= w {
+			x = w - 1
+		}
+		return gray[y][x]
+	}
+
+	for y := 0; y < h; y++ {
+		energy[y] = make([]float64, w)
+		for x := 0; x < w; x++ {
+			gx := at(y-1, x+1) + 2*at(y, x+1) + at(y+1, x+1) -
+				(at(y-1, x-1) + 2*at(y, x-1) + at(y+1, x-1))
+			gy := at(y+1, x-1) + 2*at(y+1, x) + at(y+1, x+1) -
+				(at(y-1, x-1) + 2*at(y-1, x) + at(y-1, x+1))
+			energy[y][x] = math.Sqrt(gx*gx + gy*gy)
+		}
+	}
+	return energy
+}
+
+// energyMap builds the energy grid used for seam selection, folding in the
+// optional importance mask on top of the Sobel gradient.
+func energyMap(img image.Image, mask ImportanceMask) [][]float64 {
+	energy := sobelEnergy(toGray(img))
+	if mask == nil {
+		return energy
+	}
+	extra := mask(img)
+	for y := range energy {
+		for x := range energy[y] {
+			energy[y][x] += extra[y][x]
+		}
+	}
+	return energy
+}
+
+// minSeam finds the minimum-energy top-to-bottom path through energy using
+// the standard M[i][j] = e[i][j] + min(M[i-1][j-1], M[i-1][j], M[i-1][j+1])
+// dynamic program, returning the column index of the seam for each row.
+func minSeam(energy [][]float64) []int {
+	h := len(energy)
+	w := len(energy[0])
+
+	m := make([][]float64, h)
+	choice := make([][]int, h)
+	for y := 0; y < h; y++ {
+		m[y] = make([]float64, w)
+		choice[y] = make([]int, w)
+	}
+	copy(m[0], energy[0])
+
+	for y := 1; y < h; y++ {
+		for x := 0; x < w; x++ {
+			best := m[y-1][x]
+			bestX := x
+			if x > 0 && m[y-1][x-1] < best {
+				best = m[y-1][x-1]
+				bestX = x - 1
+			}
+			if x < w-1 && m[y-1][x+1] < best {
+				best = m[y-1][x+1]
+				bestX = x + 1
+			}
+			m[y][x] = energy[y][x] + best
+			choice[y][x] = bestX
+		}
+	}
+
+	seam := make([]int, h)
+	last := h - 1
+	minX := 0
+	for x := 1; x < w; x++ {
+		if m[last][x] < m[last][minX] {
+			minX = x
+		}
+	}
+	seam[last] = minX
+	for y := last; y > 0; y-- {
+		seam[y-1] = choice[y][seam[y]]
+	}
+	return seam
+}
+
+// removeVerticalSeam returns a copy of img with one pixel removed from each
+// row at the position given by seam, shrinking the width by one.
+func removeVerticalSeam(img image.Image, seam []int) *image.NRGBA {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	out := image.NewNRGBA(image.Rect(0, 0, w-1, h))
+
+	for y := 0; y < h; y++ {
+		skip := seam[y]
+		ox := 0
+		for x := 0; x < w; x++ {
+			if x == skip {
+				continue
+			}
+			out.Set(ox, y, img.At(b.Min.X+x, b.Min.Y+y))
+			ox++
+		}
+	}
+	return out
+}
+
+// transpose swaps rows and columns so horizontal seams can reuse the
+// vertical-seam machinery.
+func transpose(img image.Image) *image.NRGBA {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	out := image.NewNRGBA(image.Rect(0, 0, h, w))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			out.Set(y, x, img.At(b.Min.X+x, b.Min.Y+y))
+		}
+	}
+	return out
+}
+
+// seamCarve shrinks img to targetW x targetH by repeatedly removing the
+// minimum-energy seam, one pixel of width or height at a time. mask may be
+// nil. Only downscaling is supported (seam insertion for upscaling isn't
+// implemented); it returns an error if targetW or targetH exceeds the
+// source dimensions rather than silently returning the image unresized.
+func seamCarve(img image.Image, targetW, targetH int, mask ImportanceMask) (image.Image, error) {
+	b := img.Bounds()
+	if targetW > b.Dx() || targetH > b.Dy() {
+		return nil, fmt.Errorf(
+			"resizer: seam carving only supports downscaling, got %dx%d source to %dx%d target",
+			b.Dx(), b.Dy(), targetW, targetH,
+		)
+	}
+
+	cur := toNRGBA(img)
+
+	for cur.Bounds().Dx() > targetW {
+		energy := energyMap(cur, mask)
+		seam := minSeam(energy)
+		cur = removeVerticalSeam(cur, seam)
+	}
+
+	if cur.Bounds().Dy() > targetH {
+		cur = transpose(cur)
+		for cur.Bounds().Dx() > targetH {
+			energy := energyMap(cur, mask)
+			seam := minSeam(energy)
+			cur = removeVerticalSeam(cur, seam)
+		}
+		cur = transpose(cur)
+	}
+
+	return cur, nil
+}
+
+// toNRGBA returns img as an *image.NRGBA: an *image.NRGBA is returned as
+// is, any other image is converted pixel by pixel into a new one.
+func toNRGBA(img image.Image) *image.NRGBA {
+	if n, ok := img.(*image.NRGBA); ok {
+		return n
+	}
+	b := img.Bounds()
+	out := image.NewNRGBA(b)
+	for y := b.Min.Y; y < b.Max.Y; y++ {
+		for x := b.Min.X; x < b.Max.X; x++ {
+			out.Set(x, y, color.NRGBAModel.Convert(img.At(x, y)))
+		}
+	}
+	return out
+}
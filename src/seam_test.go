@@ -0,0 +1,105 @@
+package main
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"testing"
+)
+
+func checkerboard(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			if (x+y)%2 == 0 {
+				img.Set(x, y, color.White)
+			} else {
+				img.Set(x, y, color.Black)
+			}
+		}
+	}
+	return img
+}
+
+func TestSeamCarveDownscale(t *testing.T) {
+	src := checkerboard(20, 12)
+
+	out, err := seamCarve(src, 15, 8, nil)
+	if err != nil {
+		t.Fatalf("seamCarve returned error: %v", err)
+	}
+	b := out.Bounds()
+	if b.Dx() != 15 || b.Dy() != 8 {
+		t.Fatalf("got %dx%d, want 15x8", b.Dx(), b.Dy())
+	}
+}
+
+func TestSeamCarveRespectsImportanceMask(t *testing.T) {
+	w, h := 20, 10
+	markerCol := 10
+	marker := color.NRGBA{R: 1, G: 2, B: 3, A: 255}
+
+	src := checkerboard(w, h)
+	for y := 0; y < h; y++ {
+		src.Set(markerCol, y, marker)
+	}
+
+	// The marker column is flat (no local gradient), so the Sobel energy
+	// map alone would rank it cheap to remove. The mask adds infinite
+	// energy over marker pixels so no seam can ever pass through them.
+	mask := ImportanceMask(func(img image.Image) [][]float64 {
+		b := img.Bounds()
+		extra := make([][]float64, b.Dy())
+		for y := range extra {
+			extra[y] = make([]float64, b.Dx())
+			for x := range extra[y] {
+				r, g, bl, _ := img.At(b.Min.X+x, b.Min.Y+y).RGBA()
+				if uint8(r>>8) == marker.R && uint8(g>>8) == marker.G && uint8(bl>>8) == marker.B {
+					extra[y][x] = math.Inf(1)
+				}
+			}
+		}
+		return extra
+	})
+
+	out, err := seamCarve(src, w-5, h, mask)
+	if err != nil {
+		t.Fatalf("seamCarve returned error: %v", err)
+	}
+
+	for y := 0; y < h; y++ {
+		found := false
+		for x := 0; x < out.Bounds().Dx(); x++ {
+			r, g, bl, _ := out.At(x, y).RGBA()
+			if uint8(r>>8) == marker.R && uint8(g>>8) == marker.G && uint8(bl>>8) == marker.B {
+				found = true
+				break
+			}
+		}
+		if !found {
+			t.Fatalf("row %d lost its protected marker pixel after seam carving", y)
+		}
+	}
+}
+
+func TestSeamCarveRejectsUpscale(t *testing.T) {
+	cases := []struct {
+		name          string
+		w, h          int
+		targetW, targetH int
+	}{
+		{"wider", 50, 50, 100, 50},
+		{"taller", 50, 50, 50, 100},
+		{"both", 50, 50, 100, 100},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			src := checkerboard(tc.w, tc.h)
+			out, err := seamCarve(src, tc.targetW, tc.targetH, nil)
+			if err == nil {
+				t.Fatalf("expected error for upscale request, got image %v", out.Bounds())
+			}
+		})
+	}
+}
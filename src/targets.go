@@ -0,0 +1,71 @@
+package main
+
+import "fmt"
+
+// TargetSpec names one output variant to produce from a single decode of
+// the source image. Set either Scale (same semantics as ResizerOptions.
+// Scale) or one/both of MaxWidth/MaxHeight to fit the image within a box
+// while preserving aspect ratio; MaxWidth/MaxHeight take precedence when
+// both are set.
+type TargetSpec struct {
+	Name      string
+	Scale     int
+	MaxWidth  int
+	MaxHeight int
+	// Suffix is appended to the source basename for this target's output
+	// filename, e.g. "-thumb" producing "photo-thumb.jpg".
+	Suffix string
+}
+
+// resizeSpec is the resolved, cache-keyable form of either a TargetSpec or
+// the legacy single-Scale behavior used when no Targets are configured.
+type resizeSpec struct {
+	suffix    string
+	variant   string
+	scale     int
+	maxWidth  int
+	maxHeight int
+	useMax    bool
+}
+
+// validateTargets rejects any TargetSpec that sets neither Scale nor
+// MaxWidth/MaxHeight, which would otherwise reach width()/height() with a
+// zero scale and panic on divide-by-zero.
+func validateTargets(targets []TargetSpec) error {
+	for _, t := range targets {
+		if t.Scale == 0 && t.MaxWidth <= 0 && t.MaxHeight <= 0 {
+			return fmt.Errorf("resizer: target %q must set Scale or MaxWidth/MaxHeight", t.Name)
+		}
+	}
+	return nil
+}
+
+func (r *Resizer) specs() []resizeSpec {
+	if len(r.targets) == 0 {
+		return []resizeSpec{{
+			scale:   r.sc,
+			variant: fmt.Sprintf("scale:%d", r.sc),
+		}}
+	}
+
+	specs := make([]resizeSpec, len(r.targets))
+	for i, t := range r.targets {
+		specs[i] = resizeSpec{
+			suffix:    t.Suffix,
+			scale:     t.Scale,
+			maxWidth:  t.MaxWidth,
+			maxHeight: t.MaxHeight,
+			useMax:    t.MaxWidth > 0 || t.MaxHeight > 0,
+			variant:   fmt.Sprintf("target:%s:scale=%d:max=%dx%d", t.Name, t.Scale, t.MaxWidth, t.MaxHeight),
+		}
+	}
+	return specs
+}
+
+// dimensions resolves this spec's output size for a source of srcW x srcH.
+func (sp resizeSpec) dimensions(srcW, srcH int) (uint, uint) {
+	if sp.useMax {
+		return fitDimensions(srcW, srcH, sp.maxWidth, sp.maxHeight)
+	}
+	return width(srcW, sp.scale), height(srcH, sp.scale)
+}
@@ -0,0 +1,46 @@
+package main
+
+import "testing"
+
+func TestValidateTargetsRejectsEmptySpec(t *testing.T) {
+	err := validateTargets([]TargetSpec{{Name: "bad"}})
+	if err == nil {
+		t.Fatal("expected error for target with neither Scale nor MaxWidth/MaxHeight set")
+	}
+}
+
+func TestValidateTargetsAcceptsScaleOrMax(t *testing.T) {
+	cases := []TargetSpec{
+		{Name: "scaled", Scale: -2},
+		{Name: "max-width", MaxWidth: 64},
+		{Name: "max-height", MaxHeight: 64},
+	}
+	for _, t2 := range cases {
+		if err := validateTargets([]TargetSpec{t2}); err != nil {
+			t.Errorf("target %q: unexpected error: %v", t2.Name, err)
+		}
+	}
+}
+
+func TestResizeSpecDimensions(t *testing.T) {
+	cases := []struct {
+		name          string
+		sp            resizeSpec
+		srcW, srcH    int
+		wantW, wantH  uint
+	}{
+		{"scale down", resizeSpec{scale: -2}, 100, 50, 50, 25},
+		{"max width only", resizeSpec{useMax: true, maxWidth: 64}, 128, 64, 64, 32},
+		{"max height only", resizeSpec{useMax: true, maxHeight: 32}, 128, 64, 64, 32},
+		{"max both, width-bound", resizeSpec{useMax: true, maxWidth: 64, maxHeight: 1000}, 128, 64, 64, 32},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			w, h := tc.sp.dimensions(tc.srcW, tc.srcH)
+			if w != tc.wantW || h != tc.wantH {
+				t.Errorf("got %dx%d, want %dx%d", w, h, tc.wantW, tc.wantH)
+			}
+		})
+	}
+}